@@ -0,0 +1,210 @@
+package oidcauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNoDeviceAuthorizationEndpoint is returned by DeviceLogin when the provider's discovery
+// document does not advertise a device_authorization_endpoint and no fallback was configured via
+// WithDeviceAuthorizationEndpoint.
+var ErrNoDeviceAuthorizationEndpoint = errors.New("provider does not advertise a device_authorization_endpoint; configure one with WithDeviceAuthorizationEndpoint")
+
+// defaultDevicePollInterval is used when the device authorization response omits "interval", per
+// the RFC 8628 recommendation.
+const defaultDevicePollInterval = 5 * time.Second
+
+// DeviceLogin performs the OAuth 2.0 Device Authorization Grant (RFC 8628): it requests a device
+// and user code from the device authorization endpoint, prints the verification URL and user code
+// to stderr for the person to open in any browser (their own machine, their phone, ...), then polls
+// the token endpoint until they complete the verification, deny it, or the device code expires.
+//
+// Unlike BrowserLogin, DeviceLogin needs neither a local browser nor a loopback listener, so it
+// works from headless servers, containers and SSH sessions. The returned token plugs into
+// StoreTokenInCache the same way a BrowserLogin token does.
+func (c *Client) DeviceLogin(ctx context.Context) (*oauth2.Token, error) {
+	endpoint, err := c.resolveDeviceAuthorizationEndpoint(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceAuth, err := requestDeviceAuthorization(ctx, endpoint, c.clientID, c.oauth2Config.Scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start device authorization: %w", err)
+	}
+
+	if deviceAuth.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "To continue, open %s\n", deviceAuth.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(os.Stderr, "To continue, open %s and enter code %s\n", deviceAuth.VerificationURI, deviceAuth.UserCode)
+	}
+
+	interval := time.Duration(deviceAuth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDevicePollInterval
+	}
+
+	deadline := time.Now().Add(time.Duration(deviceAuth.ExpiresIn) * time.Second)
+
+	for {
+		if deviceAuth.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, fmt.Errorf("device code expired before authorization was completed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		token, slowDown, err := pollDeviceToken(ctx, c.oauth2Config.Endpoint.TokenURL, c.clientID, deviceAuth.DeviceCode)
+		switch {
+		case err != nil:
+			return nil, err
+		case slowDown:
+			interval += defaultDevicePollInterval
+		case token != nil:
+			return token, nil
+		}
+	}
+}
+
+func (c *Client) resolveDeviceAuthorizationEndpoint(ctx context.Context) (string, error) {
+	if c.deviceAuthorizationEndpoint != "" {
+		return c.deviceAuthorizationEndpoint, nil
+	}
+
+	var claims struct {
+		DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	}
+	if err := c.provider.Claims(&claims); err != nil {
+		return "", fmt.Errorf("failed to parse provider discovery document: %w", err)
+	}
+
+	if claims.DeviceAuthorizationEndpoint == "" {
+		return "", ErrNoDeviceAuthorizationEndpoint
+	}
+
+	return claims.DeviceAuthorizationEndpoint, nil
+}
+
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+func requestDeviceAuthorization(ctx context.Context, endpoint, clientID string, scopes []string) (*deviceAuthorization, error) {
+	values := url.Values{"client_id": {clientID}}
+	if len(scopes) > 0 {
+		values.Set("scope", strings.Join(scopes, " "))
+	}
+
+	body, err := postForm(ctx, endpoint, values)
+	if err != nil {
+		return nil, err
+	}
+
+	var deviceAuth deviceAuthorization
+	if err := json.Unmarshal(body, &deviceAuth); err != nil {
+		return nil, fmt.Errorf("failed to decode device authorization response: %w", err)
+	}
+
+	return &deviceAuth, nil
+}
+
+// pollDeviceToken makes a single device_code token request. It returns a non-nil token on success,
+// slowDown=true if the caller should back off per RFC 8628 section 3.5, and otherwise nil, false,
+// nil when authorization is still pending.
+func pollDeviceToken(ctx context.Context, tokenURL, clientID, deviceCode string) (token *oauth2.Token, slowDown bool, err error) {
+	values := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {deviceCode},
+		"client_id":   {clientID},
+	}
+
+	body, err := postForm(ctx, tokenURL, values)
+	if err != nil {
+		var tokenErr *deviceTokenError
+		if errors.As(err, &tokenErr) {
+			switch tokenErr.ErrorCode {
+			case "authorization_pending":
+				return nil, false, nil
+			case "slow_down":
+				return nil, true, nil
+			case "access_denied":
+				return nil, false, fmt.Errorf("device login was denied")
+			case "expired_token":
+				return nil, false, fmt.Errorf("device code expired before authorization was completed")
+			}
+		}
+		return nil, false, err
+	}
+
+	var tokenResponse struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, false, fmt.Errorf("failed to decode device token response: %w", err)
+	}
+
+	t := &oauth2.Token{
+		AccessToken:  tokenResponse.AccessToken,
+		TokenType:    tokenResponse.TokenType,
+		RefreshToken: tokenResponse.RefreshToken,
+	}
+	if tokenResponse.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+
+	return t, false, nil
+}
+
+// deviceTokenError is the RFC 8628 section 3.5 error response returned by the token endpoint while
+// polling ("authorization_pending", "slow_down", "access_denied" or "expired_token").
+type deviceTokenError struct {
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (e *deviceTokenError) Error() string {
+	if e.ErrorDescription != "" {
+		return fmt.Sprintf("%s: %s", e.ErrorCode, e.ErrorDescription)
+	}
+	return e.ErrorCode
+}
+
+// postForm wraps doFormPost with the device flow's error shape: a non-200 response is expected to
+// carry a JSON {"error": "..."} body (e.g. "authorization_pending"), which is surfaced as a
+// *deviceTokenError so pollDeviceToken can switch on it.
+func postForm(ctx context.Context, endpoint string, values url.Values) ([]byte, error) {
+	status, body, err := doFormPost(ctx, endpoint, values)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != http.StatusOK {
+		var tokenErr deviceTokenError
+		if json.Unmarshal(body, &tokenErr) == nil && tokenErr.ErrorCode != "" {
+			return nil, &tokenErr
+		}
+		return nil, fmt.Errorf("request to %s failed with status %d: %s", endpoint, status, body)
+	}
+
+	return body, nil
+}
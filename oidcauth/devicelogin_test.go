@@ -0,0 +1,199 @@
+package oidcauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDeviceLogin(t *testing.T) {
+	c, _, mux := newTestProvider(t)
+
+	pollCount := 0
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code":               "test-device-code",
+			"user_code":                 "ABCD-EFGH",
+			"verification_uri":          c.issuer + "/verify",
+			"verification_uri_complete": c.issuer + "/verify?user_code=ABCD-EFGH",
+			"expires_in":                600,
+			"interval":                  1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		pollCount++
+		w.Header().Set("Content-Type", "application/json")
+		if pollCount < 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "authorization_pending"})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "device-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+
+	token, err := c.DeviceLogin(context.Background())
+	if err != nil {
+		t.Fatalf("DeviceLogin: %v", err)
+	}
+	if token.AccessToken != "device-access-token" {
+		t.Fatalf("AccessToken = %q, want %q", token.AccessToken, "device-access-token")
+	}
+	if pollCount < 2 {
+		t.Fatalf("token endpoint polled %d times, want at least 2 (authorization_pending then success)", pollCount)
+	}
+}
+
+func TestDeviceLoginNoDeviceAuthorizationEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                srv.URL,
+			"authorization_endpoint":                srv.URL + "/authorize",
+			"token_endpoint":                        srv.URL + "/token",
+			"jwks_uri":                              srv.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{}})
+	})
+
+	c, err := NewWithContext(context.Background(), srv.URL, "test-client")
+	if err != nil {
+		t.Fatalf("NewWithContext: %v", err)
+	}
+
+	if _, err := c.DeviceLogin(context.Background()); err != ErrNoDeviceAuthorizationEndpoint {
+		t.Fatalf("DeviceLogin without a device_authorization_endpoint returned %v, want %v", err, ErrNoDeviceAuthorizationEndpoint)
+	}
+}
+
+func TestPollDeviceTokenPending(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "authorization_pending"})
+	})
+
+	token, slowDown, err := pollDeviceToken(context.Background(), srv.URL+"/token", "test-client", "test-device-code")
+	if err != nil {
+		t.Fatalf("pollDeviceToken: %v", err)
+	}
+	if token != nil {
+		t.Fatalf("token = %+v, want nil while authorization is pending", token)
+	}
+	if slowDown {
+		t.Fatal("slowDown = true, want false for authorization_pending")
+	}
+}
+
+func TestPollDeviceTokenSlowDown(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "slow_down"})
+	})
+
+	token, slowDown, err := pollDeviceToken(context.Background(), srv.URL+"/token", "test-client", "test-device-code")
+	if err != nil {
+		t.Fatalf("pollDeviceToken: %v", err)
+	}
+	if token != nil {
+		t.Fatalf("token = %+v, want nil for slow_down", token)
+	}
+	if !slowDown {
+		t.Fatal("slowDown = false, want true for slow_down")
+	}
+}
+
+func TestPollDeviceTokenAccessDenied(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "access_denied"})
+	})
+
+	if _, _, err := pollDeviceToken(context.Background(), srv.URL+"/token", "test-client", "test-device-code"); err == nil {
+		t.Fatal("pollDeviceToken with access_denied returned no error")
+	}
+}
+
+func TestPollDeviceTokenExpiredToken(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": "expired_token"})
+	})
+
+	if _, _, err := pollDeviceToken(context.Background(), srv.URL+"/token", "test-client", "test-device-code"); err == nil {
+		t.Fatal("pollDeviceToken with expired_token returned no error")
+	}
+}
+
+func TestPollDeviceTokenSuccess(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "device-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+
+	token, slowDown, err := pollDeviceToken(context.Background(), srv.URL+"/token", "test-client", "test-device-code")
+	if err != nil {
+		t.Fatalf("pollDeviceToken: %v", err)
+	}
+	if slowDown {
+		t.Fatal("slowDown = true on success, want false")
+	}
+	if token == nil || token.AccessToken != "device-access-token" {
+		t.Fatalf("token = %+v, want AccessToken %q", token, "device-access-token")
+	}
+}
+
+func TestPostFormUnrecognizedErrorBody(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte("internal server error"))
+	})
+
+	_, err := postForm(context.Background(), srv.URL+"/token", url.Values{})
+	if err == nil {
+		t.Fatal("postForm with a non-JSON error body returned no error")
+	}
+
+	if tokenErr, ok := err.(*deviceTokenError); ok {
+		t.Fatalf("postForm returned a *deviceTokenError (%v) for a body with no \"error\" field", tokenErr)
+	}
+}
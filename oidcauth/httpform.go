@@ -0,0 +1,36 @@
+package oidcauth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// doFormPost POSTs values to endpoint as application/x-www-form-urlencoded and returns the
+// response status code together with the raw body. Interpreting the status code and body (token
+// endpoints disagree on how errors are shaped, e.g. RFC 8628's device flow vs. a plain RFC 7523
+// token response) is left to the caller.
+func doFormPost(ctx context.Context, endpoint string, values url.Values) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read response from %s: %w", endpoint, err)
+	}
+
+	return resp.StatusCode, body, nil
+}
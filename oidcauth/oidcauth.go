@@ -2,8 +2,12 @@ package oidcauth
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"html"
 	"net"
 	"net/http"
 	"os/exec"
@@ -13,59 +17,137 @@ import (
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/google/uuid"
-	"github.com/zalando/go-keyring"
 	"golang.org/x/oauth2"
 )
 
 type Client struct {
-	issuer, clientID string
-	m                *sync.Mutex
-	oauth2Config     oauth2.Config
-	provider         *oidc.Provider
+	issuer, clientID            string
+	m                           *sync.Mutex
+	oauth2Config                oauth2.Config
+	provider                    *oidc.Provider
+	pkce                        bool
+	deviceAuthorizationEndpoint string
+	loopbackPorts               []int
+	cache                       TokenCache
 }
 
 var (
 	ErrNotOAuth2Transport = errors.New("http client's transport is not an oauth2.Transport")
+	ErrMissingIDToken     = errors.New("token response did not contain an id_token")
+	ErrNonceMismatch      = errors.New("id_token nonce does not match the nonce sent in the authorization request")
+
+	// errLoginAlreadyCompleted is rendered to any request hitting "/callback" after the first one
+	// has already been handled; it never escapes browserLogin as a returned error.
+	errLoginAlreadyCompleted = errors.New("login already completed in another request")
 )
 
+// Option configures optional behavior of a Client created via New or NewWithContext. An Option
+// that fails to apply (e.g. invalid arguments) returns an error, which New/NewWithContext
+// propagate to their caller instead of panicking or failing silently.
+type Option func(*Client) error
+
+// WithScopes adds additional OAuth2 scopes to request beyond the default "openid" scope.
+func WithScopes(scopes ...string) Option {
+	return func(c *Client) error {
+		c.oauth2Config.Scopes = append(c.oauth2Config.Scopes, scopes...)
+		return nil
+	}
+}
+
+// WithPKCE enables or disables PKCE (RFC 7636) for BrowserLogin.
+// PKCE is enabled by default, as Client only ever acts as a public client (it never holds a client secret).
+func WithPKCE(enabled bool) Option {
+	return func(c *Client) error {
+		c.pkce = enabled
+		return nil
+	}
+}
+
+// WithDeviceAuthorizationEndpoint overrides the endpoint DeviceLogin posts the device
+// authorization request to. By default it is parsed from the provider's discovery document, which
+// older IdPs (or older go-oidc versions that don't surface it via the discovered metadata) may omit.
+func WithDeviceAuthorizationEndpoint(url string) Option {
+	return func(c *Client) error {
+		c.deviceAuthorizationEndpoint = url
+		return nil
+	}
+}
+
+// WithLoopbackPort pins BrowserLogin's local callback listener to a specific loopback port,
+// instead of letting the OS pick a free ephemeral one. Useful when the IdP's redirect URI
+// configuration only whitelists specific ports.
+func WithLoopbackPort(port int) Option {
+	return func(c *Client) error {
+		c.loopbackPorts = []int{port}
+		return nil
+	}
+}
+
+// WithLoopbackPortRange restricts BrowserLogin's local callback listener to the inclusive port
+// range [from, to]: each port is tried in turn until one can be bound. Useful when the IdP's
+// redirect URI configuration only whitelists a range of ports.
+func WithLoopbackPortRange(from, to int) Option {
+	return func(c *Client) error {
+		if from > to {
+			return fmt.Errorf("invalid loopback port range [%d, %d]: from must not be greater than to", from, to)
+		}
+
+		ports := make([]int, 0, to-from+1)
+		for port := from; port <= to; port++ {
+			ports = append(ports, port)
+		}
+
+		c.loopbackPorts = ports
+		return nil
+	}
+}
+
 // New creates a new oidc client for login, using context.Background as context for endpoint discovery.
-// The oidc client autoregisters the "openid" scope in scopes, so only additional scopes have to be specified.
-func New(issuer, clientID string, scopes ...string) (*Client, error) {
-	return NewWithContext(context.Background(), issuer, clientID, scopes...)
+func New(issuer, clientID string, opts ...Option) (*Client, error) {
+	return NewWithContext(context.Background(), issuer, clientID, opts...)
 }
 
 // NewWithContext creates a new oidc client for login, using ctx for endpoint discovery.
-// The oidc client autoregisters the "openid" scope in scopes, so only additional scopes have to be specified.
-func NewWithContext(ctx context.Context, issuer, clientID string, scopes ...string) (*Client, error) {
+func NewWithContext(ctx context.Context, issuer, clientID string, opts ...Option) (*Client, error) {
 	issuer = strings.TrimRight(issuer, "/")
 	provider, err := oidc.NewProvider(ctx, issuer)
 	if err != nil {
 		return nil, err
 	}
 
-	scopes = append(scopes, oidc.ScopeOpenID)
-
-	return &Client{
+	c := &Client{
 		m:        &sync.Mutex{},
 		clientID: clientID,
 		issuer:   issuer,
 		provider: provider,
+		pkce:     true,
+		cache:    keyringCache{},
 		oauth2Config: oauth2.Config{
 			ClientID: clientID,
 			// Discovery returns the OAuth2 endpoints.
 			Endpoint: provider.Endpoint(),
 			// "openid" is a required scope for OpenID Connect flows.
-			Scopes: scopes,
+			Scopes: []string{oidc.ScopeOpenID},
 		},
-	}, nil
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
 }
 
-// LoginWithCache tries to retrieve a refresh token from the system's keyring if present.
-// If that fails, a browser based login to the oidc issuer is triggered to retrieve a token using loginContext.
+// LoginWithCache tries to retrieve a token from the client's TokenCache (the system keyring by
+// default, see WithTokenCache) if present. If no token is cached, a browser based login to the
+// oidc issuer is triggered to retrieve a token using loginContext; an error from the TokenCache
+// itself is returned as is, without a browser login.
 // A http client is then build using httpClientContext as its context and the token for providing automated authentication and refresh.
 // For documentation on the used keyring see StoreTokenInCache.
 func (c *Client) LoginWithCache(loginContext, httpClientContext context.Context) (*http.Client, error) {
-	token, err := getTokenFromKeyring(c.issuer, c.clientID)
+	token, err := c.cache.Get(c.issuer, c.clientID)
 	if err != nil {
 		return nil, err
 	}
@@ -80,19 +162,11 @@ func (c *Client) LoginWithCache(loginContext, httpClientContext context.Context)
 	return c.oauth2Config.Client(httpClientContext, token), nil
 }
 
-func getTokenFromKeyring(issuer, clientID string) (*oauth2.Token, error) {
-	refreshToken, err := keyring.Get(issuer, clientID)
-	if err != nil {
-		return nil, err
-	}
-
-	return &oauth2.Token{
-		RefreshToken: refreshToken,
-	}, nil
-}
-
-// StoreTokenInCache extracts an oauth2 token from the http clients transport layer (provided it is a *oauth2.Transport).
-// The token's refresh token is then stored in the system's keyring.
+// StoreTokenInCache extracts an oauth2 token from the http clients transport layer (provided it is
+// a *oauth2.Transport) and stores it in the client's TokenCache (the system keyring by default,
+// see WithTokenCache). The full token (access token, refresh token, expiry and id_token) is
+// stored, not just the refresh token, so a still-valid access token can be reused without
+// re-triggering a browser login on every invocation.
 // The keyring used is chosen based on GOOS:
 //     Linux: DBus Secret Service (needs default collection "login")
 //     Darwin: /usr/bin/security (OS X keychain)
@@ -107,12 +181,13 @@ func (c *Client) StoreTokenInCache(client *http.Client) error {
 		return err
 	}
 
-	return keyring.Set(c.issuer, c.clientID, token.RefreshToken)
+	return c.cache.Set(c.issuer, c.clientID, token)
 }
 
-// DeleteTokenFromKeyring deletes a refresh token associated with the client's issuer and clientID from the system's keyring.
+// DeleteTokenFromKeyring deletes the token cached for the client's issuer and clientID from its
+// TokenCache (the system keyring by default, see WithTokenCache).
 func (c *Client) DeleteTokenFromKeyring() error {
-	return keyring.Delete(c.issuer, c.clientID)
+	return c.cache.Delete(c.issuer, c.clientID)
 }
 
 // BrowserLogin triggers a login with the client's oidc issuer in the system's browser and returns the retrieved token.
@@ -122,11 +197,54 @@ func (c *Client) DeleteTokenFromKeyring() error {
 //     Windows: cmd /c start url
 //     Darwin: open url
 func (c *Client) BrowserLogin(loginContext context.Context) (*oauth2.Token, error) {
+	token, _, err := c.browserLogin(loginContext)
+	return token, err
+}
+
+// LoginWithIDToken performs the same browser based login as BrowserLogin, but additionally
+// verifies the id_token returned alongside the access token: signature, issuer, audience,
+// expiry and nonce are all checked via an oidc.IDTokenVerifier built from the client's provider.
+// It fails closed if the token response does not contain an id_token or if verification fails.
+func (c *Client) LoginWithIDToken(loginContext context.Context) (*oauth2.Token, *oidc.IDToken, error) {
+	token, nonce, err := c.browserLogin(loginContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	idToken, err := c.verifyIDToken(loginContext, token, nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, idToken, nil
+}
+
+// Claims verifies the id_token attached to token and unmarshals its claims into v, e.g. a
+// struct with `json` tags for "sub", "email", or groups claims. See LoginWithIDToken for how
+// to obtain a token carrying a verified id_token.
+func (c *Client) Claims(token *oauth2.Token, v interface{}) error {
+	idToken, err := c.verifyIDToken(context.Background(), token, "")
+	if err != nil {
+		return err
+	}
+
+	return idToken.Claims(v)
+}
+
+// browserLogin is the shared implementation behind BrowserLogin and LoginWithIDToken. It
+// returns the exchanged token together with the nonce that was sent in the authorization
+// request, so callers can validate it against the token's id_token.
+//
+// The callback is served by a server local to this call (not the package-level DefaultServeMux),
+// so concurrent or repeated logins don't collide over the "/callback" route. The server shuts
+// itself down as soon as the callback has been handled, and loginContext cancellation aborts the
+// wait for it.
+func (c *Client) browserLogin(loginContext context.Context) (*oauth2.Token, string, error) {
 	c.m.Lock()
 	defer c.m.Unlock()
-	listener, err := net.Listen("tcp", "localhost:0")
+	listener, err := c.listenLoopback()
 	if err != nil {
-		return nil, fmt.Errorf("unable to start localhost listener")
+		return nil, "", err
 	}
 
 	port := listener.Addr().(*net.TCPAddr).Port
@@ -134,38 +252,170 @@ func (c *Client) BrowserLogin(loginContext context.Context) (*oauth2.Token, erro
 	c.oauth2Config.RedirectURL = fmt.Sprintf("http://localhost:%d/callback", port)
 
 	state := uuid.New()
+	nonce := uuid.New()
 
-	err = openBrowser(c.oauth2Config.AuthCodeURL(state.String()))
-	if err != nil {
-		return nil, fmt.Errorf("failed at opening browser: %w", err)
+	authCodeOptions := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("nonce", nonce.String())}
+	var exchangeOptions []oauth2.AuthCodeOption
+	if c.pkce {
+		verifier, err := generatePKCEVerifier()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to generate pkce code verifier: %w", err)
+		}
+
+		authCodeOptions = append(authCodeOptions,
+			oauth2.SetAuthURLParam("code_challenge", pkceCodeChallengeS256(verifier)),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+		exchangeOptions = append(exchangeOptions, oauth2.SetAuthURLParam("code_verifier", verifier))
 	}
 
-	var httpError error
-	var token *oauth2.Token
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Query().Get("state") != state.String() {
-			httpError = fmt.Errorf("state does not match")
-			return
+	var (
+		token       *oauth2.Token
+		callbackErr error
+		done        = make(chan struct{})
+		once        sync.Once
+	)
+
+	mux := http.NewServeMux()
+	srv := &http.Server{Handler: mux}
+
+	// http.Server runs each request in its own goroutine, so a second concurrent hit on
+	// "/callback" (a browser retry, a duplicate tab, ...) must not race the first one on token/
+	// callbackErr. once ensures only the first request that reaches here ever reads or writes
+	// them; any later request just gets told the login already finished.
+	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
+		handledByThisRequest := false
+
+		once.Do(func() {
+			handledByThisRequest = true
+			defer func() {
+				close(done)
+				go srv.Shutdown(context.Background())
+			}()
+
+			if r.URL.Query().Get("state") != state.String() {
+				callbackErr = fmt.Errorf("state does not match")
+				writeLoginResultPage(w, callbackErr)
+				return
+			}
+
+			oauth2Token, err := c.oauth2Config.Exchange(loginContext, r.URL.Query().Get("code"), exchangeOptions...)
+			if err != nil {
+				callbackErr = fmt.Errorf("failed to exchange token: %w", err)
+				writeLoginResultPage(w, callbackErr)
+				return
+			}
+
+			token = oauth2Token
+			writeLoginResultPage(w, nil)
+		})
+
+		if !handledByThisRequest {
+			writeLoginResultPage(w, errLoginAlreadyCompleted)
 		}
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	if err := openBrowser(c.oauth2Config.AuthCodeURL(state.String(), authCodeOptions...)); err != nil {
+		srv.Close()
+		return nil, "", fmt.Errorf("failed at opening browser: %w", err)
+	}
+
+	select {
+	case <-done:
+		if err := <-serveErr; err != nil && err != http.ErrServerClosed {
+			return nil, "", err
+		}
+	case <-loginContext.Done():
+		srv.Close()
+		return nil, "", loginContext.Err()
+	}
+
+	if callbackErr != nil {
+		return nil, "", callbackErr
+	}
 
-		oauth2Token, err := c.oauth2Config.Exchange(loginContext, r.URL.Query().Get("code"))
+	return token, nonce.String(), nil
+}
+
+// listenLoopback binds the loopback listener BrowserLogin serves its callback on, honoring
+// WithLoopbackPort/WithLoopbackPortRange if configured, or an OS-chosen ephemeral port otherwise.
+func (c *Client) listenLoopback() (net.Listener, error) {
+	if len(c.loopbackPorts) == 0 {
+		listener, err := net.Listen("tcp", "localhost:0")
 		if err != nil {
-			httpError = fmt.Errorf("failed to exchange token: %w", err)
-			return
+			return nil, fmt.Errorf("unable to start localhost listener: %w", err)
 		}
+		return listener, nil
+	}
 
-		token = oauth2Token
-	})
+	var lastErr error
+	for _, port := range c.loopbackPorts {
+		listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+		if err == nil {
+			return listener, nil
+		}
+		lastErr = err
+	}
 
-	if err = http.Serve(listener, nil); err != nil {
-		return nil, err
+	return nil, fmt.Errorf("unable to bind to any of the configured loopback ports: %w", lastErr)
+}
+
+// writeLoginResultPage renders a minimal HTML page telling the user whether the login succeeded,
+// so the tab opened by openBrowser doesn't linger on a blank screen.
+func writeLoginResultPage(w http.ResponseWriter, loginErr error) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if loginErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "<html><body><h1>Login failed</h1><p>%s</p><p>You can close this tab.</p></body></html>", html.EscapeString(loginErr.Error()))
+		return
 	}
 
-	if httpError != nil {
-		return nil, httpError
+	fmt.Fprint(w, "<html><body><h1>Login successful</h1><p>You can close this tab and return to the application.</p></body></html>")
+}
+
+// verifyIDToken extracts the id_token from token, verifies it against the client's provider
+// and, if nonce is non-empty, checks it matches the id_token's nonce claim.
+func (c *Client) verifyIDToken(ctx context.Context, token *oauth2.Token, nonce string) (*oidc.IDToken, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, ErrMissingIDToken
 	}
 
-	return token, nil
+	verifier := c.provider.Verifier(&oidc.Config{ClientID: c.clientID})
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+
+	if nonce != "" && idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	return idToken, nil
+}
+
+// pkceVerifierBytes is the amount of random bytes used to build a PKCE code verifier.
+// base64url-encoded without padding this yields an 86 character verifier, well within
+// the 43-128 character range required by RFC 7636.
+const pkceVerifierBytes = 64
+
+func generatePKCEVerifier() (string, error) {
+	b := make([]byte, pkceVerifierBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func pkceCodeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
 }
 
 func openBrowser(url string) error {
@@ -0,0 +1,192 @@
+package oidcauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"golang.org/x/oauth2"
+)
+
+// newTestProvider spins up a local fake OIDC provider serving a discovery document and a JWKS
+// endpoint, and returns a Client wired up against it, a signer for minting ID tokens the client's
+// provider will accept, and the mux backing it so callers can register additional endpoints
+// (e.g. "/token", "/device") for flows that hit them directly.
+func newTestProvider(t *testing.T) (*Client, *jose.Signer, *http.ServeMux) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	jwk := jose.JSONWebKey{Key: &key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"}
+	jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{jwk}}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                srv.URL,
+			"authorization_endpoint":                srv.URL + "/authorize",
+			"token_endpoint":                        srv.URL + "/token",
+			"jwks_uri":                              srv.URL + "/keys",
+			"device_authorization_endpoint":         srv.URL + "/device",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks)
+	})
+
+	c, err := NewWithContext(context.Background(), srv.URL, "test-client")
+	if err != nil {
+		t.Fatalf("NewWithContext: %v", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test-key"),
+	)
+	if err != nil {
+		t.Fatalf("jose.NewSigner: %v", err)
+	}
+
+	return c, &signer, mux
+}
+
+func signIDToken(t *testing.T, signer jose.Signer, issuer, audience, nonce string) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := struct {
+		jwt.Claims
+		Nonce string `json:"nonce,omitempty"`
+	}{
+		Claims: jwt.Claims{
+			Issuer:   issuer,
+			Subject:  "test-subject",
+			Audience: jwt.Audience{audience},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+		Nonce: nonce,
+	}
+
+	raw, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to sign id_token: %v", err)
+	}
+
+	return raw
+}
+
+func TestVerifyIDTokenNonceMismatch(t *testing.T) {
+	c, signer, _ := newTestProvider(t)
+
+	rawIDToken := signIDToken(t, *signer, c.issuer, c.clientID, "expected-nonce")
+	token := (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]interface{}{"id_token": rawIDToken})
+
+	_, err := c.verifyIDToken(context.Background(), token, "a-different-nonce")
+	if err != ErrNonceMismatch {
+		t.Fatalf("verifyIDToken with mismatched nonce returned %v, want %v", err, ErrNonceMismatch)
+	}
+}
+
+func TestVerifyIDTokenNonceMatch(t *testing.T) {
+	c, signer, _ := newTestProvider(t)
+
+	rawIDToken := signIDToken(t, *signer, c.issuer, c.clientID, "expected-nonce")
+	token := (&oauth2.Token{AccessToken: "access-token"}).WithExtra(map[string]interface{}{"id_token": rawIDToken})
+
+	idToken, err := c.verifyIDToken(context.Background(), token, "expected-nonce")
+	if err != nil {
+		t.Fatalf("verifyIDToken with matching nonce returned error: %v", err)
+	}
+	if idToken.Subject != "test-subject" {
+		t.Fatalf("verified id_token subject = %q, want %q", idToken.Subject, "test-subject")
+	}
+}
+
+func TestVerifyIDTokenMissingIDToken(t *testing.T) {
+	c, _, _ := newTestProvider(t)
+
+	_, err := c.verifyIDToken(context.Background(), &oauth2.Token{AccessToken: "access-token"}, "")
+	if err != ErrMissingIDToken {
+		t.Fatalf("verifyIDToken without an id_token returned %v, want %v", err, ErrMissingIDToken)
+	}
+}
+
+func TestGeneratePKCEVerifier(t *testing.T) {
+	verifier, err := generatePKCEVerifier()
+	if err != nil {
+		t.Fatalf("generatePKCEVerifier: %v", err)
+	}
+
+	if len(verifier) < 43 || len(verifier) > 128 {
+		t.Fatalf("verifier length = %d, want between 43 and 128 (RFC 7636)", len(verifier))
+	}
+
+	for _, r := range verifier {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			t.Fatalf("verifier contains disallowed character %q", r)
+		}
+	}
+
+	other, err := generatePKCEVerifier()
+	if err != nil {
+		t.Fatalf("generatePKCEVerifier: %v", err)
+	}
+	if verifier == other {
+		t.Fatal("generatePKCEVerifier returned the same verifier twice in a row")
+	}
+}
+
+func TestPKCECodeChallengeS256Deterministic(t *testing.T) {
+	verifier := "a-fixed-test-verifier-value-for-reproducible-hashing"
+
+	challenge := pkceCodeChallengeS256(verifier)
+	if challenge != pkceCodeChallengeS256(verifier) {
+		t.Fatal("pkceCodeChallengeS256 is not deterministic for the same verifier")
+	}
+
+	if pkceCodeChallengeS256(verifier+"x") == challenge {
+		t.Fatal("pkceCodeChallengeS256 produced the same challenge for different verifiers")
+	}
+}
+
+func TestWithLoopbackPortRangeInvalid(t *testing.T) {
+	c := &Client{}
+	err := WithLoopbackPortRange(9000, 8000)(c)
+	if err == nil {
+		t.Fatal("WithLoopbackPortRange(9000, 8000) returned no error for an inverted range")
+	}
+}
+
+func TestWithLoopbackPortRangeValid(t *testing.T) {
+	c := &Client{}
+	if err := WithLoopbackPortRange(8000, 8002)(c); err != nil {
+		t.Fatalf("WithLoopbackPortRange: %v", err)
+	}
+
+	want := []int{8000, 8001, 8002}
+	if len(c.loopbackPorts) != len(want) {
+		t.Fatalf("loopbackPorts = %v, want %v", c.loopbackPorts, want)
+	}
+	for i, p := range want {
+		if c.loopbackPorts[i] != p {
+			t.Fatalf("loopbackPorts = %v, want %v", c.loopbackPorts, want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package oidcauth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/cryptosigner"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// jwtAssertionLifetime is how long a jwt-bearer assertion built by JWTProfile remains valid for
+// exchange at the token endpoint. Assertions are single-use and rebuilt on every token request, so
+// this only needs to cover clock skew and network latency.
+const jwtAssertionLifetime = 2 * time.Minute
+
+// ClientCredentials authenticates using the OAuth2 Client Credentials grant (RFC 6749 section 4.4)
+// directly against the discovered token endpoint, using clientSecret. Unlike BrowserLogin this
+// never opens a browser, making it suitable for daemons and CI that have no interactive user.
+// The client's scopes (the default "openid" plus anything added via WithScopes) are always
+// requested; scopes passed here are merged in on top, for scopes that only apply to this flow.
+func (c *Client) ClientCredentials(ctx context.Context, clientSecret string, scopes ...string) *http.Client {
+	cfg := clientcredentials.Config{
+		ClientID:     c.clientID,
+		ClientSecret: clientSecret,
+		TokenURL:     c.oauth2Config.Endpoint.TokenURL,
+		Scopes:       mergeScopes(c.oauth2Config.Scopes, scopes),
+	}
+
+	return cfg.Client(ctx)
+}
+
+// mergeScopes combines base with extra, dropping duplicates while preserving base's order.
+func mergeScopes(base, extra []string) []string {
+	seen := make(map[string]bool, len(base)+len(extra))
+	merged := make([]string, 0, len(base)+len(extra))
+
+	for _, scope := range append(append([]string{}, base...), extra...) {
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		merged = append(merged, scope)
+	}
+
+	return merged
+}
+
+// JWTProfile authenticates using the JWT Bearer Token grant (RFC 7523), often called the "JWT
+// profile" or service account flow: instead of a client secret or user session, the caller proves
+// its identity by signing a short-lived JWT with its own private key and trading it in for an
+// access token at the discovered token endpoint. keyID identifies signer's public key as published
+// in the IdP's JWKS and is sent as the JWT's "kid" header; audience is normally the token endpoint;
+// subject identifies the principal the resulting token is issued for, which for a pure service
+// account is usually clientID itself.
+func (c *Client) JWTProfile(ctx context.Context, keyID string, signer crypto.Signer, audience, subject string) (*http.Client, error) {
+	joseSigner, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: cryptosigner.Opaque(signer)},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build jwt-bearer signer: %w", err)
+	}
+
+	src := &jwtBearerTokenSource{
+		ctx:      ctx,
+		tokenURL: c.oauth2Config.Endpoint.TokenURL,
+		issuer:   c.clientID,
+		subject:  subject,
+		audience: audience,
+		signer:   joseSigner,
+	}
+
+	return oauth2.NewClient(ctx, oauth2.ReuseTokenSource(nil, src)), nil
+}
+
+// jwtBearerTokenSource implements oauth2.TokenSource for the RFC 7523 JWT bearer grant. Each call
+// to Token builds and signs a fresh, short-lived assertion, since a previously-used one may have
+// been rejected by the IdP as replayed.
+type jwtBearerTokenSource struct {
+	ctx      context.Context
+	tokenURL string
+	issuer   string
+	subject  string
+	audience string
+	signer   jose.Signer
+}
+
+func (s *jwtBearerTokenSource) Token() (*oauth2.Token, error) {
+	now := time.Now()
+	claims := jwt.Claims{
+		Issuer:   s.issuer,
+		Subject:  s.subject,
+		Audience: jwt.Audience{s.audience},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(jwtAssertionLifetime)),
+		ID:       uuid.New().String(),
+	}
+
+	assertion, err := jwt.Signed(s.signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign jwt-bearer assertion: %w", err)
+	}
+
+	values := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+
+	status, body, err := doFormPost(s.ctx, s.tokenURL, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request jwt-bearer token: %w", err)
+	}
+
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("jwt-bearer token request failed with status %d: %s", status, body)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("failed to decode jwt-bearer token response: %w", err)
+	}
+
+	token := &oauth2.Token{
+		AccessToken: tokenResponse.AccessToken,
+		TokenType:   tokenResponse.TokenType,
+	}
+	if tokenResponse.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,109 @@
+package oidcauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+func TestClientCredentials(t *testing.T) {
+	c, _, mux := newTestProvider(t)
+	c.oauth2Config.Scopes = append(c.oauth2Config.Scopes, "read:foo")
+
+	var gotScope string
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		if got := r.FormValue("grant_type"); got != "client_credentials" {
+			t.Errorf("grant_type = %q, want client_credentials", got)
+		}
+		gotScope = r.FormValue("scope")
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "service-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+
+	client := c.ClientCredentials(context.Background(), "client-secret")
+
+	resp, err := client.Get(c.issuer + "/protected")
+	if err != nil {
+		t.Fatalf("client.Get: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotScope != "openid read:foo" {
+		t.Fatalf("requested scope = %q, want %q (client-level WithScopes must be honored)", gotScope, "openid read:foo")
+	}
+}
+
+func TestJWTProfile(t *testing.T) {
+	c, _, mux := newTestProvider(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey: %v", err)
+	}
+
+	var gotAssertion string
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm: %v", err)
+		}
+		if got := r.FormValue("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q, want urn:ietf:params:oauth:grant-type:jwt-bearer", got)
+		}
+		gotAssertion = r.FormValue("assertion")
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "jwt-bearer-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+
+	client, err := c.JWTProfile(context.Background(), "test-key", key, c.issuer, c.clientID)
+	if err != nil {
+		t.Fatalf("JWTProfile: %v", err)
+	}
+
+	resp, err := client.Get(c.issuer + "/protected")
+	if err != nil {
+		t.Fatalf("client.Get: %v", err)
+	}
+	resp.Body.Close()
+
+	if gotAssertion == "" {
+		t.Fatal("token endpoint did not receive an assertion")
+	}
+
+	tok, err := jwt.ParseSigned(gotAssertion)
+	if err != nil {
+		t.Fatalf("failed to parse assertion as a JWT: %v", err)
+	}
+
+	var claims jwt.Claims
+	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		t.Fatalf("failed to decode assertion claims: %v", err)
+	}
+
+	if claims.Issuer != c.clientID {
+		t.Fatalf("assertion issuer = %q, want %q", claims.Issuer, c.clientID)
+	}
+	if claims.Subject != c.clientID {
+		t.Fatalf("assertion subject = %q, want %q", claims.Subject, c.clientID)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != c.issuer {
+		t.Fatalf("assertion audience = %v, want [%q]", claims.Audience, c.issuer)
+	}
+}
@@ -0,0 +1,353 @@
+package oidcauth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+)
+
+// TokenCache persists oauth2 tokens keyed by issuer and clientID, so LoginWithCache can skip a
+// browser login on subsequent runs. Implementations must be safe for concurrent use. Get must
+// return (nil, nil), not an error, when no token is cached for issuer/clientID.
+type TokenCache interface {
+	Get(issuer, clientID string) (*oauth2.Token, error)
+	Set(issuer, clientID string, t *oauth2.Token) error
+	Delete(issuer, clientID string) error
+}
+
+// WithTokenCache overrides the TokenCache a Client uses for LoginWithCache, StoreTokenInCache and
+// DeleteTokenFromKeyring. By default, Client uses the system keyring (see StoreTokenInCache for
+// which backend that is per GOOS). Use NewMemoryTokenCache for tests, or NewFileTokenCache on
+// systems where the keyring is unavailable, e.g. headless Linux servers without a DBus Secret
+// Service.
+func WithTokenCache(cache TokenCache) Option {
+	return func(c *Client) error {
+		c.cache = cache
+		return nil
+	}
+}
+
+// cachedToken is the JSON representation a TokenCache stores a token as. Unlike the refresh token
+// alone, this captures the full token so a still-valid access token can be reused without
+// refreshing (or re-triggering a browser login) on every invocation.
+type cachedToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+	IDToken      string    `json:"id_token,omitempty"`
+}
+
+func marshalCachedToken(t *oauth2.Token) ([]byte, error) {
+	c := cachedToken{
+		AccessToken:  t.AccessToken,
+		TokenType:    t.TokenType,
+		RefreshToken: t.RefreshToken,
+		Expiry:       t.Expiry,
+	}
+	if idToken, ok := t.Extra("id_token").(string); ok {
+		c.IDToken = idToken
+	}
+
+	return json.Marshal(c)
+}
+
+func unmarshalCachedToken(data []byte) (*oauth2.Token, error) {
+	var c cachedToken
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to decode cached token: %w", err)
+	}
+
+	t := &oauth2.Token{
+		AccessToken:  c.AccessToken,
+		TokenType:    c.TokenType,
+		RefreshToken: c.RefreshToken,
+		Expiry:       c.Expiry,
+	}
+	if c.IDToken != "" {
+		t = t.WithExtra(map[string]interface{}{"id_token": c.IDToken})
+	}
+
+	return t, nil
+}
+
+// keyringCache is the default TokenCache, backed by the system's keyring.
+type keyringCache struct{}
+
+func (keyringCache) Get(issuer, clientID string) (*oauth2.Token, error) {
+	raw, err := keyring.Get(issuer, clientID)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return unmarshalCachedToken([]byte(raw))
+}
+
+func (keyringCache) Set(issuer, clientID string, t *oauth2.Token) error {
+	raw, err := marshalCachedToken(t)
+	if err != nil {
+		return err
+	}
+
+	return keyring.Set(issuer, clientID, string(raw))
+}
+
+func (keyringCache) Delete(issuer, clientID string) error {
+	err := keyring.Delete(issuer, clientID)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// MemoryTokenCache is a TokenCache that only lives for the lifetime of the process. It is mainly
+// useful for tests that exercise LoginWithCache without touching the system keyring or disk.
+type MemoryTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]*oauth2.Token
+}
+
+// NewMemoryTokenCache creates an empty MemoryTokenCache.
+func NewMemoryTokenCache() *MemoryTokenCache {
+	return &MemoryTokenCache{tokens: make(map[string]*oauth2.Token)}
+}
+
+func (m *MemoryTokenCache) Get(issuer, clientID string) (*oauth2.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.tokens[tokenCacheKey(issuer, clientID)], nil
+}
+
+func (m *MemoryTokenCache) Set(issuer, clientID string, t *oauth2.Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tokens[tokenCacheKey(issuer, clientID)] = t
+	return nil
+}
+
+func (m *MemoryTokenCache) Delete(issuer, clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.tokens, tokenCacheKey(issuer, clientID))
+	return nil
+}
+
+func tokenCacheKey(issuer, clientID string) string {
+	return issuer + "|" + clientID
+}
+
+// PassphraseFunc supplies the passphrase FileTokenCache derives its encryption key from. It is
+// called once per Set and once per Get of an existing entry (Delete never calls it), so it may
+// prompt interactively if desired.
+type PassphraseFunc func() ([]byte, error)
+
+// FileTokenCache stores tokens as AES-GCM encrypted JSON files under
+// $XDG_CONFIG_HOME/oidcauth/<hash(issuer,clientID)>.json. It exists as an escape hatch for systems
+// where the system keyring silently fails to work, e.g. headless Linux servers, WSL, and minimal
+// containers without a DBus Secret Service.
+type FileTokenCache struct {
+	dir        string
+	passphrase PassphraseFunc
+}
+
+// NewFileTokenCache creates a FileTokenCache rooted at $XDG_CONFIG_HOME/oidcauth, creating that
+// directory if necessary. If passphrase is nil, a machine-bound secret is derived automatically
+// (see machineBoundPassphrase) so tokens require no user interaction to cache, but are unreadable
+// if the file is copied to another machine. Pass a PassphraseFunc to prompt the user instead.
+func NewFileTokenCache(passphrase PassphraseFunc) (*FileTokenCache, error) {
+	dir, err := tokenCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create token cache directory: %w", err)
+	}
+
+	if passphrase == nil {
+		passphrase = machineBoundPassphrase
+	}
+
+	return &FileTokenCache{dir: dir, passphrase: passphrase}, nil
+}
+
+func tokenCacheDir() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome != "" {
+		return filepath.Join(configHome, "oidcauth"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to determine XDG_CONFIG_HOME fallback: %w", err)
+	}
+
+	return filepath.Join(home, ".config", "oidcauth"), nil
+}
+
+// machineBoundPassphrase derives a passphrase from /etc/machine-id, falling back to the hostname
+// and home directory on systems that don't have one. It is best-effort: the goal is to keep a
+// copied cache file from being readable elsewhere, not to resist a determined local attacker.
+func machineBoundPassphrase() ([]byte, error) {
+	if id, err := os.ReadFile("/etc/machine-id"); err == nil {
+		return bytes.TrimSpace(id), nil
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "oidcauth"
+	}
+
+	home, _ := os.UserHomeDir()
+
+	return []byte(hostname + ":" + home), nil
+}
+
+func (f *FileTokenCache) Get(issuer, clientID string) (*oauth2.Token, error) {
+	raw, err := os.ReadFile(f.path(issuer, clientID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := f.decrypt(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt cached token: %w", err)
+	}
+
+	return unmarshalCachedToken(plaintext)
+}
+
+func (f *FileTokenCache) Set(issuer, clientID string, t *oauth2.Token) error {
+	plaintext, err := marshalCachedToken(t)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := f.encrypt(plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt token for caching: %w", err)
+	}
+
+	// Write to a temporary file and rename it into place, so concurrent Gets and Sets never see a
+	// partially written file.
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(ciphertext); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(issuer, clientID))
+}
+
+func (f *FileTokenCache) Delete(issuer, clientID string) error {
+	err := os.Remove(f.path(issuer, clientID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (f *FileTokenCache) path(issuer, clientID string) string {
+	sum := sha256.Sum256([]byte(tokenCacheKey(issuer, clientID)))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// scryptSaltSize is the size in bytes of the random per-file salt prefixed to every cache file,
+// so scrypt derives a distinct key even when the same passphrase protects multiple files.
+const scryptSaltSize = 16
+
+// scrypt cost parameters, chosen per the package's recommendation for interactive logins
+// (RFC 7914 section 2 suggests N=2^14 as a minimum; N=2^15 costs ~2x that for extra margin).
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+func (f *FileTokenCache) gcm(salt []byte) (cipher.AEAD, error) {
+	passphrase, err := f.passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain cache passphrase: %w", err)
+	}
+
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive cache encryption key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (f *FileTokenCache) encrypt(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := f.gcm(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, plaintext, nil)
+	return append(salt, sealed...), nil
+}
+
+func (f *FileTokenCache) decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < scryptSaltSize {
+		return nil, fmt.Errorf("cached token file is truncated")
+	}
+	salt, rest := ciphertext[:scryptSaltSize], ciphertext[scryptSaltSize:]
+
+	gcm, err := f.gcm(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(rest) < nonceSize {
+		return nil, fmt.Errorf("cached token file is truncated")
+	}
+
+	nonce, sealed := rest[:nonceSize], rest[nonceSize:]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
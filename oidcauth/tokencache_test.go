@@ -0,0 +1,124 @@
+package oidcauth
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func testPassphrase() ([]byte, error) {
+	return []byte("correct horse battery staple"), nil
+}
+
+func TestFileTokenCacheRoundTrip(t *testing.T) {
+	cache := &FileTokenCache{dir: t.TempDir(), passphrase: testPassphrase}
+
+	token := (&oauth2.Token{
+		AccessToken:  "access-token",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh-token",
+		Expiry:       time.Now().Add(time.Hour).Truncate(time.Second),
+	}).WithExtra(map[string]interface{}{"id_token": "header.payload.signature"})
+
+	if err := cache.Set("https://issuer.example.com", "client-id", token); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	got, err := cache.Get("https://issuer.example.com", "client-id")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got == nil {
+		t.Fatal("Get returned nil token after Set")
+	}
+
+	if got.AccessToken != token.AccessToken ||
+		got.TokenType != token.TokenType ||
+		got.RefreshToken != token.RefreshToken ||
+		!got.Expiry.Equal(token.Expiry) {
+		t.Fatalf("round-tripped token = %+v, want %+v", got, token)
+	}
+
+	if idToken, _ := got.Extra("id_token").(string); idToken != "header.payload.signature" {
+		t.Fatalf("round-tripped id_token = %q, want %q", idToken, "header.payload.signature")
+	}
+}
+
+func TestFileTokenCacheGetMissing(t *testing.T) {
+	cache := &FileTokenCache{dir: t.TempDir(), passphrase: testPassphrase}
+
+	token, err := cache.Get("https://issuer.example.com", "client-id")
+	if err != nil {
+		t.Fatalf("Get on empty cache returned error: %v", err)
+	}
+	if token != nil {
+		t.Fatalf("Get on empty cache returned %+v, want nil", token)
+	}
+}
+
+func TestFileTokenCacheDelete(t *testing.T) {
+	cache := &FileTokenCache{dir: t.TempDir(), passphrase: testPassphrase}
+
+	token := &oauth2.Token{AccessToken: "access-token"}
+	if err := cache.Set("https://issuer.example.com", "client-id", token); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if err := cache.Delete("https://issuer.example.com", "client-id"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	got, err := cache.Get("https://issuer.example.com", "client-id")
+	if err != nil {
+		t.Fatalf("Get after Delete: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("Get after Delete = %+v, want nil", got)
+	}
+
+	// Deleting an already-absent entry must not error.
+	if err := cache.Delete("https://issuer.example.com", "client-id"); err != nil {
+		t.Fatalf("Delete on missing entry: %v", err)
+	}
+}
+
+func TestFileTokenCacheDecryptTruncated(t *testing.T) {
+	cache := &FileTokenCache{dir: t.TempDir(), passphrase: testPassphrase}
+
+	if _, err := cache.decrypt([]byte("short")); err == nil {
+		t.Fatal("decrypt of a ciphertext shorter than the GCM nonce size returned no error")
+	}
+}
+
+func TestFileTokenCacheWrongPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	writer := &FileTokenCache{dir: dir, passphrase: testPassphrase}
+
+	if err := writer.Set("https://issuer.example.com", "client-id", &oauth2.Token{AccessToken: "access-token"}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	reader := &FileTokenCache{dir: dir, passphrase: func() ([]byte, error) { return []byte("a different passphrase"), nil }}
+	if _, err := reader.Get("https://issuer.example.com", "client-id"); err == nil {
+		t.Fatal("Get with the wrong passphrase returned no error")
+	}
+}
+
+func TestMachineBoundPassphraseDeterministic(t *testing.T) {
+	first, err := machineBoundPassphrase()
+	if err != nil {
+		t.Fatalf("machineBoundPassphrase: %v", err)
+	}
+	second, err := machineBoundPassphrase()
+	if err != nil {
+		t.Fatalf("machineBoundPassphrase: %v", err)
+	}
+
+	if len(first) == 0 {
+		t.Fatal("machineBoundPassphrase returned an empty passphrase")
+	}
+	if string(first) != string(second) {
+		t.Fatalf("machineBoundPassphrase is not deterministic: %q != %q", first, second)
+	}
+}